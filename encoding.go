@@ -0,0 +1,155 @@
+package zipassets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultGzipMIMETypes is the set of content types GzipOnLoad compresses
+// when no explicit list is given: text formats plus the common web asset
+// types that are typically served as plain text.
+var defaultGzipMIMETypes = map[string]bool{
+	"text/html":              true,
+	"text/css":               true,
+	"text/plain":             true,
+	"text/xml":               true,
+	"text/javascript":        true,
+	"application/javascript": true,
+	"application/json":       true,
+	"application/xml":        true,
+	"image/svg+xml":          true,
+}
+
+// gzipCompressibleAssets adds a "<name>.gz" sibling entry for every
+// compressible, not-already-compressed file in za.files. mimeTypes may be
+// nil, in which case defaultGzipMIMETypes is used.
+func (za *ZipAssets) gzipCompressibleAssets(mimeTypes map[string]bool) error {
+	if mimeTypes == nil {
+		mimeTypes = defaultGzipMIMETypes
+	}
+
+	// Collect names first: we're about to add ".gz" entries to za.files
+	// and must not range over it while mutating it.
+	names := make([]string, 0, len(za.files))
+	for name := range za.files {
+		names = append(names, name)
+	}
+
+	for _, name := range names {
+		fc := za.files[name]
+		if fc.isDir || strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".br") {
+			continue
+		}
+		if _, exists := za.files[name+".gz"]; exists {
+			continue
+		}
+		if !mimeTypes[za.contentTypeFor(name)] {
+			continue
+		}
+
+		rc, err := fc.ranger.Range(context.Background(), 0, fc.ranger.Size())
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		gw := gzip.NewWriter(&buf)
+		if _, err := gw.Write(content); err != nil {
+			return err
+		}
+		if err := gw.Close(); err != nil {
+			return err
+		}
+
+		za.files[name+".gz"] = &filecontent{
+			name:         name + ".gz",
+			lastModified: fc.lastModified,
+			ranger:       byteRanger(buf.Bytes()),
+		}
+	}
+
+	return nil
+}
+
+// negotiateEncoding looks for a precompressed sibling of upath (preferring
+// ".br" over ".gz") that the client advertised support for via
+// Accept-Encoding, returning it and the encoding name to serve instead of
+// fc. If none applies, it returns fc unchanged and an empty encoding.
+func (za *ZipAssets) negotiateEncoding(upath string, fc *filecontent, req *http.Request) (*filecontent, string) {
+	accept := req.Header.Get("Accept-Encoding")
+	if accept == "" {
+		return fc, ""
+	}
+
+	for _, enc := range []struct{ name, ext string }{
+		{"br", ".br"},
+		{"gzip", ".gz"},
+	} {
+		if !acceptsEncoding(accept, enc.name) {
+			continue
+		}
+		if alt, ok := za.currentFiles()[upath+enc.ext]; ok {
+			return alt, enc.name
+		}
+	}
+
+	return fc, ""
+}
+
+// acceptsEncoding reports whether enc is listed in the value of an
+// Accept-Encoding header with a nonzero q-value. "enc;q=0" means the client
+// explicitly forbids enc, per RFC 7231 §5.3.1, and always wins over an
+// earlier "*" or bare "enc". "*;q=0" accepts nothing by itself but does not
+// override an explicit "enc" or "*" with a nonzero q-value.
+func acceptsEncoding(accept, enc string) bool {
+	accepted := false
+	for _, part := range strings.Split(accept, ",") {
+		name, q := parseAcceptEncodingPart(part)
+		if name != enc && name != "*" {
+			continue
+		}
+		if q == 0 {
+			if name == enc {
+				return false
+			}
+			continue
+		}
+		accepted = true
+	}
+	return accepted
+}
+
+// parseAcceptEncodingPart splits one comma-separated Accept-Encoding entry
+// ("gzip", "gzip;q=0.5", "gzip;q=0") into its coding name and q-value,
+// which defaults to 1 when absent or unparseable.
+func parseAcceptEncodingPart(part string) (name string, q float64) {
+	q = 1
+	name = strings.TrimSpace(part)
+	i := strings.Index(name, ";")
+	if i < 0 {
+		return name, q
+	}
+
+	params := name[i+1:]
+	name = strings.TrimSpace(name[:i])
+	for _, p := range strings.Split(params, ";") {
+		p = strings.TrimSpace(p)
+		if !strings.HasPrefix(p, "q=") {
+			continue
+		}
+		if f, err := strconv.ParseFloat(strings.TrimSpace(p[len("q="):]), 64); err == nil {
+			q = f
+		}
+	}
+	return name, q
+}
@@ -0,0 +1,139 @@
+package zipassets
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newEncodingTestZipAssets() *ZipAssets {
+	return &ZipAssets{
+		path: "test.zip",
+		files: map[string]*filecontent{
+			"app.js":    {name: "app.js", ranger: byteRanger("console.log(1);")},
+			"app.js.gz": {name: "app.js.gz", ranger: byteRanger(gzipBytes("console.log(1);"))},
+		},
+	}
+}
+
+func gzipBytes(s string) []byte {
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte(s))
+	gw.Close()
+	return buf.Bytes()
+}
+
+func TestServeHTTPServesPrecompressedGzip(t *testing.T) {
+	za := newEncodingTestZipAssets()
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate, br")
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rw.Code)
+	}
+	if enc := rw.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("expected Content-Encoding: gzip, got %q", enc)
+	}
+	if vary := rw.Header().Get("Vary"); vary != "Accept-Encoding" {
+		t.Fatalf("expected Vary: Accept-Encoding, got %q", vary)
+	}
+	gr, err := gzip.NewReader(rw.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := ioutil.ReadAll(gr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "console.log(1);" {
+		t.Fatalf("unexpected decompressed body %q", got)
+	}
+}
+
+func TestServeHTTPWithoutAcceptEncodingServesOriginal(t *testing.T) {
+	za := newEncodingTestZipAssets()
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if enc := rw.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("expected no Content-Encoding, got %q", enc)
+	}
+	if body := rw.Body.String(); body != "console.log(1);" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestServeHTTPQZeroRejectsEncoding(t *testing.T) {
+	za := newEncodingTestZipAssets()
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip;q=0, br")
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if enc := rw.Header().Get("Content-Encoding"); enc != "" {
+		t.Fatalf("expected gzip;q=0 to rule out the gzipped sibling, got Content-Encoding %q", enc)
+	}
+	if body := rw.Body.String(); body != "console.log(1);" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestAcceptsEncodingQValues(t *testing.T) {
+	cases := []struct {
+		accept, enc string
+		want        bool
+	}{
+		{"gzip", "gzip", true},
+		{"gzip;q=0, br", "gzip", false},
+		{"gzip;q=0, br", "br", true},
+		{"*;q=0", "gzip", false},
+		{"*;q=0, gzip", "gzip", true},
+		{"gzip;q=0.5", "gzip", true},
+	}
+	for _, c := range cases {
+		if got := acceptsEncoding(c.accept, c.enc); got != c.want {
+			t.Errorf("acceptsEncoding(%q, %q) = %v, want %v", c.accept, c.enc, got, c.want)
+		}
+	}
+}
+
+func TestGzipOnLoadCompressesTextAssets(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := dir + "/assets.zip"
+	writeTestZip(t, zipPath, map[string]string{
+		"app.js":  "console.log(1);",
+		"app.bin": "\x00\x01\x02binary",
+	})
+
+	handler, err := NewZipAssets(zipPath, GzipOnLoad())
+	if err != nil {
+		t.Fatal(err)
+	}
+	za := handler.(*ZipAssets)
+
+	if _, ok := za.files["app.js.gz"]; !ok {
+		t.Fatal("expected app.js.gz to be generated")
+	}
+	if _, ok := za.files["app.bin.gz"]; ok {
+		t.Fatal("did not expect app.bin.gz to be generated")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if enc := rw.Header().Get("Content-Encoding"); enc != "gzip" {
+		t.Fatalf("expected Content-Encoding: gzip, got %q", enc)
+	}
+}
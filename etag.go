@@ -0,0 +1,68 @@
+package zipassets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"strconv"
+)
+
+// computeETags stashes an ETag on every non-directory entry. In-memory
+// entries are hashed once here so ServeHTTP never hashes content on the
+// request path. Streaming entries skip that hash, since reading the full
+// content back out of the Ranger would re-decompress every entry at load
+// time and defeat the point of Streaming(): they instead get a cheap
+// ranger-provided validator, or a weak one derived from size and mtime.
+func (za *ZipAssets) computeETags() error {
+	for _, fc := range za.files {
+		if fc.isDir {
+			continue
+		}
+
+		if za.streaming {
+			fc.etag = cheapETagFor(fc)
+			continue
+		}
+
+		rc, err := fc.ranger.Range(context.Background(), 0, fc.ranger.Size())
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+
+		fc.etag = etagFor(content)
+	}
+	return nil
+}
+
+// etagFor computes a strong ETag for content: a double-quoted
+// "<16 hex chars of sha256>-<size in hex>", e.g. `"1a2b3c4d5e6f7089-3e8"`.
+func etagFor(content []byte) string {
+	sum := sha256.Sum256(content)
+	return `"` + hex.EncodeToString(sum[:])[:16] + "-" + strconv.FormatInt(int64(len(content)), 16) + `"`
+}
+
+// cheapETagger is implemented by Rangers that can supply an ETag basis
+// without reading their content (e.g. a zip entry's CRC32, already known
+// from the central directory). cheapETagFor falls back to a weak
+// validator when a Ranger doesn't implement it.
+type cheapETagger interface {
+	cheapETag() string
+}
+
+// cheapETagFor returns a validator for fc without reading its full
+// content.
+func cheapETagFor(fc *filecontent) string {
+	if r, ok := fc.ranger.(cheapETagger); ok {
+		return r.cheapETag()
+	}
+	// No cheap content-derived basis available (e.g. a tar member): fall
+	// back to a weak validator from size and mtime, which still changes
+	// whenever the archive is rebuilt with different content.
+	return `W/"` + strconv.FormatInt(fc.ranger.Size(), 16) + "-" + strconv.FormatInt(fc.lastModified.Unix(), 16) + `"`
+}
@@ -0,0 +1,179 @@
+package zipassets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newETagTestZipAssets() *ZipAssets {
+	za := &ZipAssets{
+		path: "test.zip",
+		files: map[string]*filecontent{
+			"app.js": {name: "app.js", lastModified: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ranger: byteRanger("console.log(1);")},
+		},
+	}
+	if err := za.computeETags(); err != nil {
+		panic(err)
+	}
+	return za
+}
+
+func TestComputeETagsSetsStrongETag(t *testing.T) {
+	za := newETagTestZipAssets()
+	etag := za.files["app.js"].etag
+	if etag == "" {
+		t.Fatal("expected a non-empty etag")
+	}
+	if etag[0] != '"' || etag[len(etag)-1] != '"' {
+		t.Fatalf("expected a quoted strong etag, got %q", etag)
+	}
+}
+
+func TestServeHTTPIfNoneMatchReturns304(t *testing.T) {
+	za := newETagTestZipAssets()
+	etag := za.files["app.js"].etag
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("If-None-Match", etag)
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusNotModified {
+		t.Fatalf("expected 304, got %d", rw.Code)
+	}
+}
+
+func TestServeHTTPIfMatchMismatchReturns412(t *testing.T) {
+	za := newETagTestZipAssets()
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("If-Match", `"does-not-match"`)
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412, got %d", rw.Code)
+	}
+}
+
+func TestServeHTTPIfMatchWeakETagReturns412(t *testing.T) {
+	za := newETagTestZipAssets()
+	etag := za.files["app.js"].etag
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	// If-Match requires strong comparison (RFC 7232 §3.1): a weak form of
+	// the current etag must never satisfy it, even though the opaque tag
+	// matches.
+	req.Header.Set("If-Match", "W/"+etag)
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412 for a weak If-Match, got %d", rw.Code)
+	}
+}
+
+func TestMatchesETagStrongRejectsWeakValidators(t *testing.T) {
+	if matchesETagStrong(`W/"abc"`, `W/"abc"`) {
+		t.Fatal("a weak current etag must never satisfy If-Match")
+	}
+	if matchesETagStrong(`"abc"`, `W/"abc"`) {
+		t.Fatal("a weak If-Match entry must never satisfy strong comparison")
+	}
+	if !matchesETagStrong(`"abc"`, `"abc"`) {
+		t.Fatal("two equal strong etags should satisfy If-Match")
+	}
+	if !matchesETagStrong(`"abc"`, `*`) {
+		t.Fatal("If-Match: * should satisfy any strong etag")
+	}
+}
+
+func TestServeHTTPIfUnmodifiedSinceStaleReturns412(t *testing.T) {
+	za := newETagTestZipAssets()
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("If-Unmodified-Since", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected 412, got %d", rw.Code)
+	}
+}
+
+func TestServeHTTPIfRangeMismatchIgnoresRange(t *testing.T) {
+	za := newETagTestZipAssets()
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Range", "bytes=0-3")
+	req.Header.Set("If-Range", `"stale-etag"`)
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200 (range ignored), got %d", rw.Code)
+	}
+	if body := rw.Body.String(); body != "console.log(1);" {
+		t.Fatalf("unexpected body %q", body)
+	}
+}
+
+func TestComputeETagsStreamingZipUsesCheapETag(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := dir + "/assets.zip"
+	writeTestZip(t, zipPath, map[string]string{"app.js": "console.log(1);"})
+
+	handler, err := NewZipAssets(zipPath, Streaming())
+	if err != nil {
+		t.Fatal(err)
+	}
+	za := handler.(*ZipAssets)
+
+	fc := za.files["app.js"]
+	if fc.etag == "" {
+		t.Fatal("expected a non-empty etag")
+	}
+	if fc.etag[0] != '"' {
+		t.Fatalf("expected a strong (CRC32-derived) etag, got %q", fc.etag)
+	}
+	if want := fc.ranger.(cheapETagger).cheapETag(); fc.etag != want {
+		t.Fatalf("expected the ranger's cheap etag %q, got %q", want, fc.etag)
+	}
+}
+
+func TestComputeETagsStreamingFallsBackToWeakETag(t *testing.T) {
+	za := &ZipAssets{
+		path:      "test.zip",
+		streaming: true,
+		files: map[string]*filecontent{
+			"app.js": {name: "app.js", lastModified: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), ranger: byteRanger("console.log(1);")},
+		},
+	}
+	if err := za.computeETags(); err != nil {
+		t.Fatal(err)
+	}
+
+	etag := za.files["app.js"].etag
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Fatalf("expected a weak etag, got %q", etag)
+	}
+}
+
+func TestServeHTTPRangeNotSatisfiableSetsContentRange(t *testing.T) {
+	za := newETagTestZipAssets()
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Range", "bytes=1000-2000")
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected 416, got %d", rw.Code)
+	}
+	if cr := rw.Header().Get("Content-Range"); cr != "bytes */15" {
+		t.Fatalf("expected Content-Range: bytes */15, got %q", cr)
+	}
+}
@@ -0,0 +1,70 @@
+package zipassets
+
+import (
+	"mime"
+	"path/filepath"
+	"strings"
+)
+
+// defaultMIMETypes supplies content types for extensions commonly missing
+// from the host OS's mime.types, so assets like .wasm resolve correctly
+// without a per-instance RegisterMIMEType call.
+var defaultMIMETypes = map[string]string{
+	".wasm":        "application/wasm",
+	".avif":        "image/avif",
+	".webmanifest": "application/manifest+json",
+}
+
+// RegisterMIMEType overrides the content type reported for files with the
+// given extension (e.g. ".wasm"), taking precedence over defaultMIMETypes
+// and mime.TypeByExtension. ext should include the leading dot. It's safe
+// to call concurrently with ServeHTTP and with a running Watch() reload.
+func (za *ZipAssets) RegisterMIMEType(ext, ctype string) {
+	za.mimeTypesMu.Lock()
+	if za.mimeTypes == nil {
+		za.mimeTypes = make(map[string]string)
+	}
+	za.mimeTypes[ext] = ctype
+	za.mimeTypesMu.Unlock()
+
+	// Re-cache against the currently published snapshot: if Watch() has
+	// already reloaded, za.files is stale and ServeHTTP no longer reads
+	// it, so recomputing against it would have no visible effect.
+	za.cacheContentTypes()
+}
+
+// contentTypeFor returns name's MIME type by extension, checking za's
+// overrides and defaultMIMETypes before falling back to
+// mime.TypeByExtension, whose result is stripped of any parameters (e.g.
+// "; charset=utf-8"). Overrides are returned exactly as registered.
+func (za *ZipAssets) contentTypeFor(name string) string {
+	ext := filepath.Ext(name)
+
+	za.mimeTypesMu.RLock()
+	override, ok := za.mimeTypes[ext]
+	za.mimeTypesMu.RUnlock()
+	if ok {
+		return override
+	}
+	if ctype, ok := defaultMIMETypes[ext]; ok {
+		return ctype
+	}
+
+	ctype := mime.TypeByExtension(ext)
+	if i := strings.Index(ctype, ";"); i >= 0 {
+		ctype = ctype[:i]
+	}
+	return ctype
+}
+
+// cacheContentTypes resolves and stashes a content type on every
+// non-directory entry in za's current snapshot, so a request never has to
+// consult mime.TypeByExtension itself.
+func (za *ZipAssets) cacheContentTypes() {
+	for _, fc := range za.currentFiles() {
+		if fc.isDir {
+			continue
+		}
+		fc.setContentType(za.contentTypeFor(fc.name))
+	}
+}
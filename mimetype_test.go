@@ -0,0 +1,59 @@
+package zipassets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newMIMETestZipAssets() *ZipAssets {
+	za := &ZipAssets{
+		path: "test.zip",
+		files: map[string]*filecontent{
+			"app.wasm":   {name: "app.wasm", ranger: byteRanger("wasm bytes")},
+			"module.xyz": {name: "module.xyz", ranger: byteRanger("custom bytes")},
+		},
+	}
+	za.cacheContentTypes()
+	return za
+}
+
+func TestContentTypeForDefaultMIMETypes(t *testing.T) {
+	za := newMIMETestZipAssets()
+	if ct := za.files["app.wasm"].getContentType(); ct != "application/wasm" {
+		t.Fatalf("expected application/wasm, got %q", ct)
+	}
+}
+
+func TestRegisterMIMETypeOverridesAndRecaches(t *testing.T) {
+	za := newMIMETestZipAssets()
+
+	za.RegisterMIMEType(".xyz", "application/x-custom")
+
+	if ct := za.files["module.xyz"].getContentType(); ct != "application/x-custom" {
+		t.Fatalf("expected application/x-custom, got %q", ct)
+	}
+}
+
+func TestRegisterMIMETypeDuringServeHTTPDoesNotRace(t *testing.T) {
+	za := newMIMETestZipAssets()
+
+	stop := make(chan struct{})
+	go func() {
+		for i := 0; ; i++ {
+			select {
+			case <-stop:
+				return
+			default:
+				za.RegisterMIMEType(".xyz", "application/x-custom")
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/module.xyz", nil)
+		rw := httptest.NewRecorder()
+		za.ServeHTTP(rw, req)
+	}
+	close(stop)
+}
@@ -0,0 +1,55 @@
+package zipassets
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// has reports whether za can serve name (an absolute path, as in an
+// incoming request's URL), either as a file or as an implicit directory.
+func (za *ZipAssets) has(name string) bool {
+	key := strings.TrimPrefix(path.Clean("/"+name), "/")
+	if _, ok := za.currentFiles()[key]; ok {
+		return true
+	}
+	return key == "" || za.isDir(key)
+}
+
+// overlayLayer is implemented by handlers that can report whether they'd
+// serve a given path, letting Overlay skip straight to a lower layer
+// instead of always dispatching to the last one. *ZipAssets implements it;
+// a layer that doesn't (e.g. a plain http.FileServer, such as the one
+// NewZipAssets falls back to in debug mode or on a load error) is treated
+// as a catch-all and always served.
+type overlayLayer interface {
+	has(name string) bool
+}
+
+// Overlay composes several handlers into one http.Handler, serving each
+// request from the last layer (highest index) that has a matching path,
+// so a later layer's files override an earlier one's with the same name
+// — e.g. a theme or plugin bundle layered over a base asset bundle. Its
+// elements are http.Handler, not *ZipAssets, since NewZipAssets itself
+// returns http.Handler (it can fall back to http.FileServer).
+type Overlay []http.Handler
+
+// ServeHTTP implements http.Handler.
+func (o Overlay) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if len(o) == 0 {
+		http.NotFound(rw, req)
+		return
+	}
+
+	for i := len(o) - 1; i >= 0; i-- {
+		l, ok := o[i].(overlayLayer)
+		if !ok || l.has(req.URL.Path) {
+			o[i].ServeHTTP(rw, req)
+			return
+		}
+	}
+
+	// No layer has this exact path; let the top one handle it so
+	// directory listings and index.html still work as usual.
+	o[len(o)-1].ServeHTTP(rw, req)
+}
@@ -0,0 +1,70 @@
+package zipassets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOverlayPrefersLaterArchive(t *testing.T) {
+	base := &ZipAssets{path: "base.zip", files: map[string]*filecontent{
+		"app.js":  {name: "app.js", ranger: byteRanger("base")},
+		"base.js": {name: "base.js", ranger: byteRanger("only in base")},
+	}}
+	theme := &ZipAssets{path: "theme.zip", files: map[string]*filecontent{
+		"app.js": {name: "app.js", ranger: byteRanger("theme")},
+	}}
+	base.cacheContentTypes()
+	theme.cacheContentTypes()
+
+	overlay := Overlay{base, theme}
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	rw := httptest.NewRecorder()
+	overlay.ServeHTTP(rw, req)
+	if body := rw.Body.String(); body != "theme" {
+		t.Fatalf("expected theme override, got %q", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/base.js", nil)
+	rw = httptest.NewRecorder()
+	overlay.ServeHTTP(rw, req)
+	if body := rw.Body.String(); body != "only in base" {
+		t.Fatalf("expected fallback to base, got %q", body)
+	}
+}
+
+func TestOverlayAcceptsPlainHandlerFallback(t *testing.T) {
+	base := &ZipAssets{path: "base.zip", files: map[string]*filecontent{
+		"app.js": {name: "app.js", ranger: byteRanger("base")},
+	}}
+	base.cacheContentTypes()
+
+	fallback := http.HandlerFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Write([]byte("fallback"))
+	})
+
+	overlay := Overlay{base, fallback}
+
+	req := httptest.NewRequest(http.MethodGet, "/missing.js", nil)
+	rw := httptest.NewRecorder()
+	overlay.ServeHTTP(rw, req)
+	if body := rw.Body.String(); body != "fallback" {
+		t.Fatalf("expected the plain handler to always serve, got %q", body)
+	}
+}
+
+func TestOverlayMissingPathReturns404(t *testing.T) {
+	base := &ZipAssets{path: "base.zip", files: map[string]*filecontent{
+		"app.js": {name: "app.js", ranger: byteRanger("base")},
+	}}
+	overlay := Overlay{base}
+
+	req := httptest.NewRequest(http.MethodGet, "/missing.js", nil)
+	rw := httptest.NewRecorder()
+	overlay.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rw.Code)
+	}
+}
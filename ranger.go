@@ -0,0 +1,159 @@
+package zipassets
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+)
+
+// Ranger describes content that can be read a byte range at a time without
+// necessarily holding the whole thing in memory. The shape is borrowed from
+// the ranger pattern used by Storj's eestream/ranger packages.
+type Ranger interface {
+	// Size returns the total size of the underlying content.
+	Size() int64
+	// Range returns a reader for the length bytes starting at offset. The
+	// caller must Close the returned ReadCloser.
+	Range(ctx context.Context, offset, length int64) (io.ReadCloser, error)
+}
+
+// rangeReadCloser pairs a Reader already positioned and limited to the
+// requested range with the Close logic needed to release whatever produced
+// it (a zip entry reader, a reopened file, ...).
+type rangeReadCloser struct {
+	io.Reader
+	closeFn func() error
+}
+
+func (r *rangeReadCloser) Close() error { return r.closeFn() }
+
+// byteRanger is a Ranger backed by an in-memory byte slice, used when an
+// archive is loaded entirely into RAM.
+type byteRanger []byte
+
+func (b byteRanger) Size() int64 { return int64(len(b)) }
+
+func (b byteRanger) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || length < 0 || offset+length > int64(len(b)) {
+		return nil, fmt.Errorf("zipassets: range out of bounds")
+	}
+	return ioutil.NopCloser(bytes.NewReader(b[offset : offset+length])), nil
+}
+
+// zipEntryRanger is a Ranger backed by a single entry of an open
+// *zip.ReadCloser. Reading a range re-opens (decompresses) the entry from
+// its start and discards the unwanted prefix, since zip's flate reader has
+// no random access.
+type zipEntryRanger struct {
+	f *zip.File
+}
+
+func (r *zipEntryRanger) Size() int64 { return int64(r.f.UncompressedSize64) }
+
+// cheapETag implements cheapETagger using the entry's CRC32, which the zip
+// central directory already records, so it's available without
+// decompressing the entry.
+func (r *zipEntryRanger) cheapETag() string {
+	return `"` + strconv.FormatUint(uint64(r.f.CRC32), 16) + "-" + strconv.FormatInt(r.Size(), 16) + `"`
+}
+
+func (r *zipEntryRanger) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || length < 0 || offset+length > r.Size() {
+		return nil, fmt.Errorf("zipassets: range out of bounds")
+	}
+
+	rc, err := r.f.Open()
+	if err != nil {
+		return nil, err
+	}
+	if offset > 0 {
+		if _, err := io.CopyN(ioutil.Discard, rc, offset); err != nil {
+			rc.Close()
+			return nil, err
+		}
+	}
+
+	return &rangeReadCloser{Reader: io.LimitReader(rc, length), closeFn: rc.Close}, nil
+}
+
+// tarRanger is a Ranger backed by a member of a .tar.gz/.tar.bz2 archive.
+// The archive has no random access either (it may be compressed), so a
+// range read reopens the underlying file, replays the same decompression
+// chain used when the archive was indexed, and discards bytes up to the
+// member's recorded offset within the decompressed tar stream.
+type tarRanger struct {
+	za     *ZipAssets
+	offset int64
+	size   int64
+}
+
+func (r *tarRanger) Size() int64 { return r.size }
+
+func (r *tarRanger) Range(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+	if offset < 0 || length < 0 || offset+length > r.size {
+		return nil, fmt.Errorf("zipassets: range out of bounds")
+	}
+
+	f, err := os.Open(r.za.path)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		src      io.Reader
+		closeSrc = func() error { return nil }
+	)
+	kind := r.za.archiveKind()
+	switch kind {
+	case "tar.gz":
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		src, closeSrc = gr, gr.Close
+	case "tar.bz2":
+		src = bzip2.NewReader(f)
+	default:
+		f.Close()
+		return nil, fmt.Errorf("zipassets: unsupported archive kind %q", kind)
+	}
+
+	if _, err := io.CopyN(ioutil.Discard, src, r.offset+offset); err != nil {
+		closeSrc()
+		f.Close()
+		return nil, err
+	}
+
+	return &rangeReadCloser{
+		Reader: io.LimitReader(src, length),
+		closeFn: func() error {
+			err := closeSrc()
+			if cerr := f.Close(); err == nil {
+				err = cerr
+			}
+			return err
+		},
+	}, nil
+}
+
+// countingReader wraps a reader and tracks how many bytes have been read
+// from it, so openTar can record each tar member's byte offset within the
+// decompressed stream for tarRanger.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
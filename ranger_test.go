@@ -0,0 +1,90 @@
+package zipassets
+
+import (
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestByteRangerRange(t *testing.T) {
+	r := byteRanger("hello world")
+
+	rc, err := r.Range(context.Background(), 6, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+
+	got, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != "world" {
+		t.Fatalf("expected %q, got %q", "world", got)
+	}
+}
+
+func TestByteRangerOutOfBounds(t *testing.T) {
+	r := byteRanger("hello")
+	if _, err := r.Range(context.Background(), 3, 10); err == nil {
+		t.Fatal("expected an out-of-bounds error")
+	}
+}
+
+func writeTestZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	zw := zip.NewWriter(f)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStreamingZipServesRanges(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := dir + "/assets.zip"
+	writeTestZip(t, zipPath, map[string]string{"app.js": "console.log(1);"})
+
+	handler, err := NewZipAssets(zipPath, Streaming())
+	if err != nil {
+		t.Fatal(err)
+	}
+	za, ok := handler.(*ZipAssets)
+	if !ok {
+		t.Fatalf("expected *ZipAssets, got %T", handler)
+	}
+	if !za.streaming {
+		t.Fatal("expected streaming mode to be enabled")
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+	req.Header.Set("Range", "bytes=8-13")
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusPartialContent {
+		t.Fatalf("expected 206, got %d", rw.Code)
+	}
+	if body := rw.Body.String(); body != "log(1)" {
+		t.Fatalf("unexpected range body %q", body)
+	}
+}
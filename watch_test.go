@@ -0,0 +1,85 @@
+package zipassets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestWatchReloadsOnArchiveChange(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := dir + "/assets.zip"
+	writeTestZip(t, zipPath, map[string]string{"app.js": "v1"})
+
+	handler, err := NewZipAssets(zipPath, Watch(20*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	za := handler.(*ZipAssets)
+	defer za.Close()
+
+	get := func() string {
+		req := httptest.NewRequest(http.MethodGet, "/app.js", nil)
+		rw := httptest.NewRecorder()
+		za.ServeHTTP(rw, req)
+		return rw.Body.String()
+	}
+
+	if body := get(); body != "v1" {
+		t.Fatalf("expected v1, got %q", body)
+	}
+
+	// Ensure the new file's mtime is observably later than the original's.
+	time.Sleep(20 * time.Millisecond)
+	writeTestZip(t, zipPath, map[string]string{"app.js": "v2"})
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(zipPath, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if get() == "v2" {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected reload to pick up v2, last body %q", get())
+}
+
+func TestRegisterMIMETypeDuringWatchDoesNotRace(t *testing.T) {
+	dir := t.TempDir()
+	zipPath := dir + "/assets.zip"
+	writeTestZip(t, zipPath, map[string]string{"module.xyz": "v1"})
+
+	handler, err := NewZipAssets(zipPath, Watch(time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	za := handler.(*ZipAssets)
+	defer za.Close()
+
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				writeTestZip(t, zipPath, map[string]string{"module.xyz": "v1"})
+				time.Sleep(time.Millisecond)
+			}
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		za.RegisterMIMEType(".xyz", "application/x-custom")
+	}
+	close(stop)
+
+	if ct := za.contentTypeFor("module.xyz"); ct != "application/x-custom" {
+		t.Fatalf("expected application/x-custom, got %q", ct)
+	}
+}
@@ -3,22 +3,25 @@ package zipassets
 import (
 	"archive/tar"
 	"archive/zip"
-	"bytes"
 	"compress/bzip2"
 	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
+	"html"
 	"io"
 	"io/ioutil"
-	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/textproto"
+	"net/url"
 	"os"
 	"path"
-	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -26,40 +29,473 @@ type filecontent struct {
 	name         string
 	isDir        bool
 	lastModified time.Time
-	content      []byte
+	ranger       Ranger
+	etag         string // ETag computed at load time: strong `"<sha256-prefix>-<size-hex>"` in memory, cheaper or weak when streaming (see computeETags)
+
+	// contentType is resolved from the entry's extension at load time, and
+	// again whenever RegisterMIMEType changes the overrides. It's behind
+	// an atomic.Pointer rather than a plain string because, unlike etag,
+	// it can be rewritten on an entry that's already published and being
+	// read concurrently by ServeHTTP.
+	contentType atomic.Pointer[string]
+}
+
+// getContentType returns fc's cached content type, or "" if none has been
+// resolved yet.
+func (fc *filecontent) getContentType() string {
+	if p := fc.contentType.Load(); p != nil {
+		return *p
+	}
+	return ""
+}
+
+// setContentType stores ctype as fc's cached content type.
+func (fc *filecontent) setContentType(ctype string) {
+	fc.contentType.Store(&ctype)
+}
+
+// FileInfo returns an os.FileInfo describing fc, for use with the
+// http.File / os.FileInfo based APIs (Open, Readdir, ...).
+func (fc *filecontent) FileInfo() os.FileInfo {
+	return &fileInfo{
+		name:    path.Base(fc.name),
+		size:    fc.ranger.Size(),
+		modTime: fc.lastModified,
+		isDir:   fc.isDir,
+	}
+}
+
+// fileInfo is a minimal os.FileInfo for entries served out of an archive,
+// including directories synthesized from file paths that have no explicit
+// directory entry in the archive itself.
+type fileInfo struct {
+	name    string
+	size    int64
+	modTime time.Time
+	isDir   bool
+}
+
+func (fi *fileInfo) Name() string { return fi.name }
+func (fi *fileInfo) Size() int64  { return fi.size }
+func (fi *fileInfo) Mode() os.FileMode {
+	if fi.isDir {
+		return os.ModeDir | 0555
+	}
+	return 0444
+}
+func (fi *fileInfo) ModTime() time.Time { return fi.modTime }
+func (fi *fileInfo) IsDir() bool        { return fi.isDir }
+func (fi *fileInfo) Sys() interface{}   { return nil }
+
+// rangeReadSeeker adapts a Ranger to io.ReadSeeker, so it can back an
+// http.File, by re-issuing a Range request from the new offset whenever
+// Seek moves the cursor.
+type rangeReadSeeker struct {
+	ranger Ranger
+	pos    int64
+	rc     io.ReadCloser
+}
+
+func (r *rangeReadSeeker) Read(p []byte) (int, error) {
+	if r.rc == nil {
+		size := r.ranger.Size()
+		if r.pos >= size {
+			return 0, io.EOF
+		}
+		rc, err := r.ranger.Range(context.Background(), r.pos, size-r.pos)
+		if err != nil {
+			return 0, err
+		}
+		r.rc = rc
+	}
+	n, err := r.rc.Read(p)
+	r.pos += int64(n)
+	return n, err
+}
+
+func (r *rangeReadSeeker) Seek(offset int64, whence int) (int64, error) {
+	var newPos int64
+	switch whence {
+	case io.SeekStart:
+		newPos = offset
+	case io.SeekCurrent:
+		newPos = r.pos + offset
+	case io.SeekEnd:
+		newPos = r.ranger.Size() + offset
+	default:
+		return 0, fmt.Errorf("zipassets: invalid whence %d", whence)
+	}
+	if newPos < 0 {
+		return 0, fmt.Errorf("zipassets: negative seek position")
+	}
+	if r.rc != nil {
+		r.rc.Close()
+		r.rc = nil
+	}
+	r.pos = newPos
+	return r.pos, nil
+}
+
+func (r *rangeReadSeeker) Close() error {
+	if r.rc != nil {
+		return r.rc.Close()
+	}
+	return nil
+}
+
+// httpFile adapts an archive entry (or a directory synthesized from the
+// archive's paths) to http.File, so *ZipAssets can be used as an
+// http.FileSystem.
+type httpFile struct {
+	za   *ZipAssets
+	info os.FileInfo
+	name string // full key into za.files, e.g. "static/app.js"
+	*rangeReadSeeker
+}
+
+func (f *httpFile) Close() error               { return f.rangeReadSeeker.Close() }
+func (f *httpFile) Stat() (os.FileInfo, error) { return f.info, nil }
+
+func (f *httpFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.info.IsDir() {
+		return nil, fmt.Errorf("zipassets: %s is not a directory", f.name)
+	}
+	children := f.za.readdir(f.name)
+	if count <= 0 || count > len(children) {
+		return children, nil
+	}
+	return children[:count], nil
+}
+
+// readdir lists the immediate children of dir (given as a key with no
+// leading slash, "" for the archive root).
+func (za *ZipAssets) readdir(dir string) []os.FileInfo {
+	prefix := dir
+	if prefix != "" {
+		prefix += "/"
+	}
+	seen := make(map[string]bool)
+	var list []os.FileInfo
+	for name, fc := range za.currentFiles() {
+		if name == dir || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		rest := strings.TrimSuffix(name[len(prefix):], "/")
+		if rest == "" {
+			continue
+		}
+		if i := strings.Index(rest, "/"); i >= 0 {
+			// Intermediate directory with no explicit entry in the
+			// archive; synthesize one.
+			child := rest[:i]
+			if seen[child] {
+				continue
+			}
+			seen[child] = true
+			list = append(list, &fileInfo{name: child, isDir: true})
+			continue
+		}
+		if seen[rest] {
+			continue
+		}
+		seen[rest] = true
+		list = append(list, fc.FileInfo())
+	}
+	return list
+}
+
+// isDir reports whether key is a directory implied by the archive's file
+// paths, even if the archive has no explicit entry for it.
+func (za *ZipAssets) isDir(key string) bool {
+	prefix := key + "/"
+	for name := range za.currentFiles() {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Open implements http.FileSystem, so *ZipAssets is a drop-in replacement
+// for http.Dir and can be wrapped with http.StripPrefix, http.FileServer,
+// or other FileSystem-based middleware.
+func (za *ZipAssets) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	key := strings.TrimPrefix(name, "/")
+	files := za.currentFiles()
+
+	if fc, ok := files[key]; ok {
+		ranger := fc.ranger
+		if fc.isDir {
+			ranger = byteRanger(nil)
+		}
+		return &httpFile{za: za, info: fc.FileInfo(), name: key, rangeReadSeeker: &rangeReadSeeker{ranger: ranger}}, nil
+	}
+
+	if key == "" || za.isDir(key) {
+		info := &fileInfo{name: path.Base(key), isDir: true}
+		return &httpFile{za: za, info: info, name: key, rangeReadSeeker: &rangeReadSeeker{ranger: byteRanger(nil)}}, nil
+	}
+
+	return nil, os.ErrNotExist
 }
 
 type ZipAssets struct {
-	path  string
-	files map[string]*filecontent
+	path      string
+	files     map[string]*filecontent                 // build-time map; published to filesPtr once populated
+	filesPtr  atomic.Pointer[map[string]*filecontent] // lock-free snapshot read by ServeHTTP
+	streaming bool
+
+	mimeTypesMu sync.RWMutex      // guards mimeTypes, which RegisterMIMEType can mutate concurrently with ServeHTTP and Watch reloads
+	mimeTypes   map[string]string // per-instance overrides registered via RegisterMIMEType, keyed by extension
+
+	gzipOnLoad    bool // replayed against the rebuilt map on every Watch() reload
+	gzipMIMETypes map[string]bool
+
+	watchStop chan struct{} // closed by Close to stop the Watch() polling goroutine
+
+	// archiveMu guards kind and zipReader, which reload() replaces as a
+	// pair; tarRanger.Range reads kind through it too. It's separate from
+	// the lock-free files snapshot since it's only touched by Watch-driven
+	// reloads and streaming reads, not every request.
+	archiveMu sync.Mutex
+	kind      string          // "tar.gz" or "tar.bz2"; lets a tarRanger reopen and re-decompress the archive
+	zipReader *zip.ReadCloser // kept open when streaming, so zipEntryRanger can Open() entries on demand
+}
+
+// archiveKind returns the current kind ("tar.gz" or "tar.bz2") under
+// archiveMu, so a concurrent reload can't tear a read.
+func (za *ZipAssets) archiveKind() string {
+	za.archiveMu.Lock()
+	defer za.archiveMu.Unlock()
+	return za.kind
+}
+
+// currentFiles returns the map ServeHTTP should read from: the published,
+// lock-free snapshot if Watch() has swapped one in, or the build-time map
+// otherwise (e.g. for a *ZipAssets assembled directly in tests).
+func (za *ZipAssets) currentFiles() map[string]*filecontent {
+	if p := za.filesPtr.Load(); p != nil {
+		return *p
+	}
+	return za.files
+}
+
+// publishFiles makes files the current snapshot returned by currentFiles,
+// atomically and without requiring readers to take a lock.
+func (za *ZipAssets) publishFiles(files map[string]*filecontent) {
+	za.filesPtr.Store(&files)
+}
+
+// Option configures optional behavior passed to NewZipAssets.
+type Option func(*zipAssetsConfig)
+
+type zipAssetsConfig struct {
+	debug         bool
+	streaming     bool
+	gzipOnLoad    bool
+	gzipMIMETypes map[string]bool
+	watch         bool
+	watchInterval time.Duration
+}
+
+// Streaming makes NewZipAssets keep the archive on disk and read requested
+// byte ranges on demand instead of loading every entry into memory up
+// front. Use it for large archives, or many small ones, where holding
+// everything in RAM is too costly; it trades that memory for extra I/O per
+// request (zip entries are re-decompressed from their start on every
+// range read, and tar entries reopen the archive file).
+func Streaming() Option {
+	return func(c *zipAssetsConfig) { c.streaming = true }
+}
+
+// GzipOnLoad gzips text-like assets at archive-load time, storing each as
+// a "<name>.gz" sibling entry, so ServeHTTP can transparently serve it to
+// clients that send "Accept-Encoding: gzip" without the archive having to
+// ship pre-built .gz files. An entry is skipped if it already has a ".gz"
+// sibling, if it is itself a ".gz" or ".br" file, or if its content type
+// (by extension) isn't in mimeTypes. Pass no mimeTypes to use
+// defaultGzipMIMETypes.
+func GzipOnLoad(mimeTypes ...string) Option {
+	return func(c *zipAssetsConfig) {
+		c.gzipOnLoad = true
+		if len(mimeTypes) > 0 {
+			c.gzipMIMETypes = make(map[string]bool, len(mimeTypes))
+			for _, t := range mimeTypes {
+				c.gzipMIMETypes[t] = true
+			}
+		}
+	}
+}
+
+// Watch makes NewZipAssets poll the archive's mtime every interval (2s if
+// omitted) and hot-reload it on change, atomically swapping the served
+// file map so a running server picks up new assets without a restart.
+// ServeHTTP's read path stays lock-free: it always reads the latest
+// published snapshot. Call Close on the returned handler to stop polling.
+//
+// Reloading a streaming zip archive has one caveat: a request already
+// reading an entry's bytes when a reload happens keeps using the old,
+// about-to-be-closed *zip.ReadCloser and may see a read error instead of
+// the new content. In-memory mode and tar-backed streaming aren't
+// affected, since they don't hold a handle that reload replaces.
+func Watch(interval ...time.Duration) Option {
+	return func(c *zipAssetsConfig) {
+		c.watch = true
+		c.watchInterval = 2 * time.Second
+		if len(interval) > 0 {
+			c.watchInterval = interval[0]
+		}
+	}
 }
 
 // open zip assets file
 func NewZipAssets(pathname string, args ...interface{}) (handler http.Handler, err error) {
-	if len(args) != 0 {
-		debug, ok := args[0].(bool)
-		if ok && debug == true {
-			handler = http.FileServer(http.Dir(basename(pathname)))
-			return
+	var cfg zipAssetsConfig
+	for _, a := range args {
+		switch v := a.(type) {
+		case bool:
+			cfg.debug = v
+		case Option:
+			v(&cfg)
 		}
 	}
-	za := &ZipAssets{pathname, make(map[string]*filecontent)}
-	lowerPath := strings.ToLower(pathname)
-	if strings.HasSuffix(lowerPath, ".zip") {
+	if cfg.debug {
+		handler = http.FileServer(http.Dir(basename(pathname)))
+		return
+	}
+
+	za := &ZipAssets{
+		path:          pathname,
+		files:         make(map[string]*filecontent),
+		streaming:     cfg.streaming,
+		gzipOnLoad:    cfg.gzipOnLoad,
+		gzipMIMETypes: cfg.gzipMIMETypes,
+	}
+	if err = za.load(); err != nil {
+		handler = http.FileServer(http.Dir(basename(pathname)))
+		return
+	}
+	za.publishFiles(za.files)
+
+	if cfg.watch {
+		za.watchStop = make(chan struct{})
+		go za.watchLoop(cfg.watchInterval)
+	}
+
+	return za, nil
+}
+
+// load opens za.path by extension into za.files and, once populated,
+// applies GzipOnLoad, content-type caching, and ETag generation. It is
+// used both for the initial archive load and, via reload, for Watch.
+func (za *ZipAssets) load() error {
+	lowerPath := strings.ToLower(za.path)
+	var err error
+	switch {
+	case strings.HasSuffix(lowerPath, ".zip"):
 		err = openZip(za)
-	} else if strings.HasSuffix(lowerPath, ".tar.gz") {
+	case strings.HasSuffix(lowerPath, ".tar.gz"):
 		err = openTarGz(za)
-	} else if strings.HasSuffix(lowerPath, ".tar.bz2") {
+	case strings.HasSuffix(lowerPath, ".tar.bz2"):
 		err = openTarBz2(za)
 	}
-
 	if err != nil {
-		// 切换回目录
-		handler = http.FileServer(http.Dir(basename(pathname)))
-		return
+		return err
+	}
+
+	if za.gzipOnLoad {
+		if err := za.gzipCompressibleAssets(za.gzipMIMETypes); err != nil {
+			return err
+		}
+	}
+
+	za.cacheContentTypes()
+
+	return za.computeETags()
+}
+
+// reload re-reads za.path from scratch into a new map and, on success,
+// atomically swaps it in for currentFiles to return. The previous
+// *zip.ReadCloser, if any, is closed only after the swap.
+func (za *ZipAssets) reload() error {
+	za.mimeTypesMu.RLock()
+	mimeTypes := make(map[string]string, len(za.mimeTypes))
+	for ext, ctype := range za.mimeTypes {
+		mimeTypes[ext] = ctype
+	}
+	za.mimeTypesMu.RUnlock()
+
+	fresh := &ZipAssets{
+		path:          za.path,
+		files:         make(map[string]*filecontent),
+		streaming:     za.streaming,
+		mimeTypes:     mimeTypes,
+		gzipOnLoad:    za.gzipOnLoad,
+		gzipMIMETypes: za.gzipMIMETypes,
+	}
+	if err := fresh.load(); err != nil {
+		return err
+	}
+
+	za.publishFiles(fresh.files)
+
+	za.archiveMu.Lock()
+	oldZipReader := za.zipReader
+	za.kind = fresh.kind
+	za.zipReader = fresh.zipReader
+	za.archiveMu.Unlock()
+
+	if oldZipReader != nil {
+		oldZipReader.Close()
+	}
+
+	return nil
+}
+
+// watchLoop polls za.path's mtime every interval and reloads the archive
+// when it changes, until Close is called. Reload errors are ignored: the
+// previously published snapshot keeps serving.
+func (za *ZipAssets) watchLoop(interval time.Duration) {
+	var lastModTime time.Time
+	if info, err := os.Stat(za.path); err == nil {
+		lastModTime = info.ModTime()
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-za.watchStop:
+			return
+		case <-ticker.C:
+			info, err := os.Stat(za.path)
+			if err != nil || !info.ModTime().After(lastModTime) {
+				continue
+			}
+			if err := za.reload(); err == nil {
+				lastModTime = info.ModTime()
+			}
+		}
+	}
+}
+
+// Close stops the Watch() polling goroutine, if any, and releases the
+// archive's open file handle in streaming mode.
+func (za *ZipAssets) Close() error {
+	if za.watchStop != nil {
+		close(za.watchStop)
 	}
 
-	return za, err
+	za.archiveMu.Lock()
+	zipReader := za.zipReader
+	za.archiveMu.Unlock()
+
+	if zipReader != nil {
+		return zipReader.Close()
+	}
+	return nil
 }
 
 func basename(pathname string) string {
@@ -73,7 +509,6 @@ func basename(pathname string) string {
 func openTarGz(za *ZipAssets) (err error) {
 	var (
 		f  *os.File
-		tr *tar.Reader
 		gr *gzip.Reader
 	)
 
@@ -87,52 +522,63 @@ func openTarGz(za *ZipAssets) (err error) {
 	}
 	defer gr.Close()
 
-	tr = tar.NewReader(gr)
-
-	err = openTar(za, tr)
+	za.kind = "tar.gz"
+	err = openTar(za, gr)
 
 	return
 }
 
 // deal with .tar.bz2
 func openTarBz2(za *ZipAssets) (err error) {
-	var (
-		f  *os.File
-		tr *tar.Reader
-	)
+	var f *os.File
 
 	if f, err = os.Open(za.path); err != nil {
 		return
 	}
 	defer f.Close()
 
-	tr = tar.NewReader(bzip2.NewReader(f))
-
-	err = openTar(za, tr)
+	za.kind = "tar.bz2"
+	err = openTar(za, bzip2.NewReader(f))
 
 	return
 }
 
-func openTar(za *ZipAssets, tr *tar.Reader) (err error) {
-	var (
-		hdr *tar.Header
-		fc  filecontent
-	)
+// openTar reads every member of the tar stream r (already decompressed, if
+// applicable). In streaming mode it records each member's byte offset
+// within r instead of reading its content, so a tarRanger can later replay
+// the decompression and seek to it on demand.
+func openTar(za *ZipAssets, r io.Reader) (err error) {
+	var hdr *tar.Header
+
+	cr := &countingReader{r: r}
+	tr := tar.NewReader(cr)
 
 	for {
 		if hdr, err = tr.Next(); err == io.EOF {
+			err = nil
 			break
 		}
 		if err != nil {
 			return
 		}
-		if fc.content, err = ioutil.ReadAll(tr); err != nil {
-			return
+
+		fc := &filecontent{
+			name:         hdr.Name,
+			isDir:        hdr.FileInfo().IsDir(),
+			lastModified: hdr.ModTime,
 		}
-		fc.name = hdr.Name
-		fc.lastModified = hdr.ModTime
-		fc.isDir = hdr.FileInfo().IsDir()
-		za.files[hdr.Name] = &fc
+
+		if za.streaming {
+			fc.ranger = &tarRanger{za: za, offset: cr.n, size: hdr.Size}
+		} else {
+			var content []byte
+			if content, err = ioutil.ReadAll(tr); err != nil {
+				return
+			}
+			fc.ranger = byteRanger(content)
+		}
+
+		za.files[hdr.Name] = fc
 	}
 
 	return
@@ -140,34 +586,43 @@ func openTar(za *ZipAssets, tr *tar.Reader) (err error) {
 
 // deal zip file
 func openZip(za *ZipAssets) (err error) {
-	var (
-		bytes []byte
-		rc    io.ReadCloser
-		fc    filecontent
-	)
-
 	r, err := zip.OpenReader(za.path)
 	if err != nil {
 		return
 	}
-	defer r.Close()
 
-	// Iterate through the files in the archive,
-	// printing some of their contents.
+	if za.streaming {
+		za.zipReader = r
+	} else {
+		defer r.Close()
+	}
+
 	for _, f := range r.File {
-		fmt.Println(f.Name)
-		rc, err = f.Open()
-		if err != nil {
-			return
+		fc := &filecontent{
+			name:         f.Name,
+			isDir:        f.FileInfo().IsDir(),
+			lastModified: f.ModTime(),
 		}
-		bytes, err = ioutil.ReadAll(rc)
-		if err != nil {
-			return
+
+		if za.streaming {
+			fc.ranger = &zipEntryRanger{f: f}
+		} else {
+			var (
+				rc      io.ReadCloser
+				content []byte
+			)
+			if rc, err = f.Open(); err != nil {
+				return
+			}
+			content, err = ioutil.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return
+			}
+			fc.ranger = byteRanger(content)
 		}
-		fc.name = f.Name
-		fc.lastModified = f.ModTime()
-		fc.content = bytes
-		za.files[f.Name] = &fc
+
+		za.files[f.Name] = fc
 	}
 
 	return
@@ -177,20 +632,112 @@ func openZip(za *ZipAssets) (err error) {
 // serveHttp interface
 func (za *ZipAssets) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	upath := req.URL.Path
-	if strings.HasPrefix(upath, "/") {
-		upath = upath[1:]
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
 	}
-	fc, ok := za.files[upath]
-	if !ok {
+	za.serveFile(rw, req, path.Clean(upath))
+}
+
+// serveFile resolves name (an absolute, cleaned archive path) to either a
+// file, which is streamed out through serveContent, or a directory, which
+// serves its index.html if present or an HTML listing of its children
+// otherwise. This mirrors the net/http serveFile/dirList behavior so
+// *ZipAssets acts like a drop-in replacement for http.Dir.
+func (za *ZipAssets) serveFile(rw http.ResponseWriter, req *http.Request, name string) {
+	f, err := za.Open(name)
+	if err != nil {
+		http.NotFound(rw, req)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
 		http.NotFound(rw, req)
 		return
 	}
 
-	if checkLastModified(rw, req, fc.lastModified) {
+	if info.IsDir() {
+		if url := req.URL.Path; url == "" || url[len(url)-1] != '/' {
+			localRedirect(rw, req, path.Base(url)+"/")
+			return
+		}
+
+		index := strings.TrimSuffix(name, "/") + "/index.html"
+		if ff, err := za.Open(index); err == nil {
+			defer ff.Close()
+			if fi, err := ff.Stat(); err == nil && !fi.IsDir() {
+				za.serveContent(rw, req, strings.TrimPrefix(index, "/"))
+				return
+			}
+		}
+
+		dirList(rw, req, f)
+		return
+	}
+
+	za.serveContent(rw, req, strings.TrimPrefix(name, "/"))
+}
+
+// localRedirect sends an HTTP redirect to newPath, preserving the query
+// string, without the leading path that ServeMux typically removes.
+func localRedirect(w http.ResponseWriter, r *http.Request, newPath string) {
+	if q := r.URL.RawQuery; q != "" {
+		newPath += "?" + q
+	}
+	w.Header().Set("Location", newPath)
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+// dirList writes an HTML directory listing of f's immediate children,
+// matching the layout produced by net/http's dirList.
+func dirList(rw http.ResponseWriter, req *http.Request, f http.File) {
+	dirs, err := f.Readdir(-1)
+	if err != nil {
+		http.Error(rw, "Error reading directory", http.StatusInternalServerError)
+		return
+	}
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Name() < dirs[j].Name() })
+
+	rw.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprintf(rw, "<pre>\n")
+	for _, d := range dirs {
+		name := d.Name()
+		if d.IsDir() {
+			name += "/"
+		}
+		u := url.URL{Path: name}
+		fmt.Fprintf(rw, "<a href=\"%s\">%s</a>\n", u.String(), html.EscapeString(name))
+	}
+	fmt.Fprintf(rw, "</pre>\n")
+}
+
+// serveContent streams the archive entry stored under upath (no leading
+// slash), handling conditional requests and byte ranges.
+func (za *ZipAssets) serveContent(rw http.ResponseWriter, req *http.Request, upath string) {
+	fc, ok := za.currentFiles()[upath]
+	if !ok {
+		http.NotFound(rw, req)
 		return
 	}
 
-	rangeReq, done := checkETag(rw, req)
+	if fc.etag != "" {
+		rw.Header().Set("Etag", fc.etag)
+	}
+
+	contentFc, encoding := za.negotiateEncoding(upath, fc, req)
+	if encoding != "" {
+		rw.Header().Set("Vary", "Accept-Encoding")
+		if etag := rw.Header().Get("Etag"); strings.HasSuffix(etag, `"`) {
+			rw.Header().Set("Etag", strings.TrimSuffix(etag, `"`)+"-"+encoding+`"`)
+		}
+	}
+
+	if !fc.lastModified.IsZero() {
+		rw.Header().Set("Last-Modified", fc.lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	rangeReq, done := checkPreconditions(rw, req, rw.Header().Get("Etag"), fc.lastModified)
 	if done {
 		return
 	}
@@ -200,35 +747,48 @@ func (za *ZipAssets) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	ctypes, haveType := rw.Header()["Content-Type"]
 	var ctype string
 	if !haveType {
-		ctype = mime.TypeByExtension(filepath.Ext(upath))
+		ctype = fc.getContentType()
 		if ctype == "" {
 			// read a chunk to decide between utf-8 text and binary
 			const sniffLen = 512
-			var (
-				n   int
-				buf []byte
-			)
-			if len(fc.content) >= 512 {
-				n = 512
-			} else {
-				n = len(fc.content)
+			n := sniffLen
+			if size := fc.ranger.Size(); size < int64(n) {
+				n = int(size)
+			}
+			sniff, err := fc.ranger.Range(req.Context(), 0, int64(n))
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
 			}
-			copy(buf, fc.content[:n])
-			ctype = http.DetectContentType(buf[:n])
+			buf, err := ioutil.ReadAll(sniff)
+			sniff.Close()
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			ctype = http.DetectContentType(buf)
 		}
+		rw.Header().Set("Content-Type", ctype)
 	} else if len(ctypes) > 0 {
 		ctype = ctypes[0]
 	}
 
+	if encoding != "" {
+		rw.Header().Set("Content-Encoding", encoding)
+	}
+
 	var (
 		size, sendSize int64
-		sendContent    io.Reader = bytes.NewReader(fc.content)
+		sendContent    io.ReadCloser
 	)
-	size = int64(len(fc.content))
+	size = contentFc.ranger.Size()
 	sendSize = size
 	if size >= 0 {
 		ranges, err := parseRange(rangeReq, size)
 		if err != nil {
+			if err == errNoOverlap {
+				rw.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", size))
+			}
 			http.Error(rw, err.Error(), http.StatusRequestedRangeNotSatisfiable)
 			return
 		}
@@ -240,6 +800,12 @@ func (za *ZipAssets) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			ranges = nil
 		}
 		switch {
+		case len(ranges) == 0:
+			sendContent, err = contentFc.ranger.Range(req.Context(), 0, size)
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
 		case len(ranges) == 1:
 			// RFC 2616, Section 14.16:
 			// "When an HTTP message includes the content of a single
@@ -256,10 +822,15 @@ func (za *ZipAssets) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 			sendSize = ra.length
 			code = http.StatusPartialContent
 			rw.Header().Set("Content-Range", ra.contentRange(size))
+			sendContent, err = contentFc.ranger.Range(req.Context(), ra.start, ra.length)
+			if err != nil {
+				http.Error(rw, err.Error(), http.StatusInternalServerError)
+				return
+			}
 		case len(ranges) > 1:
 			for _, ra := range ranges {
 				if ra.start > size {
-					http.Error(rw, err.Error(), http.StatusRequestedRangeNotSatisfiable)
+					http.Error(rw, "invalid range", http.StatusRequestedRangeNotSatisfiable)
 					return
 				}
 			}
@@ -278,20 +849,17 @@ func (za *ZipAssets) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 						pw.CloseWithError(err)
 						return
 					}
-					if _, err := io.CopyN(part, bytes.NewReader(fc.content[ra.start:size]), ra.length); err != nil {
+					rc, err := contentFc.ranger.Range(req.Context(), ra.start, ra.length)
+					if err != nil {
+						pw.CloseWithError(err)
+						return
+					}
+					_, err = io.CopyN(part, rc, ra.length)
+					rc.Close()
+					if err != nil {
 						pw.CloseWithError(err)
 						return
 					}
-					/*
-						if _, err := content.Seek(ra.start, os.SEEK_SET); err != nil {
-							pw.CloseWithError(err)
-							return
-						}
-						if _, err := io.CopyN(part, content, ra.length); err != nil {
-							pw.CloseWithError(err)
-							return
-						}
-					*/
 				}
 				mw.Close()
 				pw.Close()
@@ -308,71 +876,51 @@ func (za *ZipAssets) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	if req.Method != "HEAD" {
 		io.CopyN(rw, sendContent, sendSize)
 	}
-
-}
-
-// modtime is the modification time of the resource to be served, or IsZero().
-// return value is whether this request is now complete.
-func checkLastModified(w http.ResponseWriter, r *http.Request, modtime time.Time) bool {
-	if modtime.IsZero() {
-		return false
-	}
-
-	// The Date-Modified header truncates sub-second precision, so
-	// use mtime < t+1s instead of mtime <= t to check for unmodified.
-	if t, err := time.Parse(http.TimeFormat, r.Header.Get("If-Modified-Since")); err == nil && modtime.Before(t.Add(1*time.Second)) {
-		h := w.Header()
-		delete(h, "Content-Type")
-		delete(h, "Content-Length")
-		w.WriteHeader(http.StatusNotModified)
-		return true
+	if sendContent != nil {
+		sendContent.Close()
 	}
-	w.Header().Set("Last-Modified", modtime.UTC().Format(http.TimeFormat))
-	return false
 }
 
-// checkETag implements If-None-Match and If-Range checks.
-// The ETag must have been previously set in the ResponseWriter's headers.
+// checkPreconditions implements the HTTP precondition ladder from RFC 7232
+// (If-Match, If-Unmodified-Since, If-None-Match, If-Modified-Since) plus
+// If-Range, in the precedence order net/http's ServeContent uses: If-Match
+// takes priority over If-Unmodified-Since, and If-None-Match over
+// If-Modified-Since. etag is the response's current ETag ("" if none);
+// modtime may be its IsZero() value if the entry has none.
 //
 // The return value is the effective request "Range" header to use and
-// whether this request is now considered done.
-func checkETag(w http.ResponseWriter, r *http.Request) (rangeReq string, done bool) {
-	etag := w.Header().Get("Etag")
+// whether the response is already complete (a 304 or 412 was written).
+func checkPreconditions(w http.ResponseWriter, r *http.Request, etag string, modtime time.Time) (rangeReq string, done bool) {
 	rangeReq = r.Header.Get("Range")
 
-	// Invalidate the range request if the entity doesn't match the one
-	// the client was expecting.
-	// "If-Range: version" means "ignore the Range: header unless version matches the
-	// current file."
-	// We only support ETag versions.
-	// The caller must have set the ETag on the response already.
-	if ir := r.Header.Get("If-Range"); ir != "" && ir != etag {
-		// TODO(bradfitz): handle If-Range requests with Last-Modified
-		// times instead of ETags? I'd rather not, at least for
-		// now. That seems like a bug/compromise in the RFC 2616, and
-		// I've never heard of anybody caring about that (yet).
-		rangeReq = ""
+	if im := r.Header.Get("If-Match"); im != "" {
+		if !matchesETagStrong(etag, im) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return "", true
+		}
+	} else if ius := r.Header.Get("If-Unmodified-Since"); ius != "" && !modtime.IsZero() {
+		if t, err := time.Parse(http.TimeFormat, ius); err == nil && modtime.After(t.Add(1*time.Second)) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			return "", true
+		}
 	}
 
 	if inm := r.Header.Get("If-None-Match"); inm != "" {
-		// Must know ETag.
-		if etag == "" {
-			return rangeReq, false
-		}
-
-		// TODO(bradfitz): non-GET/HEAD requests require more work:
-		// sending a different status code on matches, and
-		// also can't use weak cache validators (those with a "W/
-		// prefix).  But most users of ServeContent will be using
-		// it on GET or HEAD, so only support those for now.
-		if r.Method != "GET" && r.Method != "HEAD" {
-			return rangeReq, false
+		if matchesETag(etag, inm) {
+			h := w.Header()
+			delete(h, "Content-Type")
+			delete(h, "Content-Length")
+			if r.Method == "GET" || r.Method == "HEAD" {
+				w.WriteHeader(http.StatusNotModified)
+			} else {
+				w.WriteHeader(http.StatusPreconditionFailed)
+			}
+			return "", true
 		}
-
-		// TODO(bradfitz): deal with comma-separated or multiple-valued
-		// list of If-None-match values.  For now just handle the common
-		// case of a single item.
-		if inm == etag || inm == "*" {
+	} else if ims := r.Header.Get("If-Modified-Since"); ims != "" && !modtime.IsZero() {
+		// The Date-Modified header truncates sub-second precision, so
+		// use mtime < t+1s instead of mtime <= t to check for unmodified.
+		if t, err := time.Parse(http.TimeFormat, ims); err == nil && modtime.Before(t.Add(1*time.Second)) {
 			h := w.Header()
 			delete(h, "Content-Type")
 			delete(h, "Content-Length")
@@ -380,9 +928,81 @@ func checkETag(w http.ResponseWriter, r *http.Request) (rangeReq string, done bo
 			return "", true
 		}
 	}
+
+	// "If-Range: version" means "ignore the Range: header unless version
+	// matches the current entity." version may be an ETag or a
+	// Last-Modified date.
+	if ir := r.Header.Get("If-Range"); ir != "" && !rangeMatchesIfRange(ir, etag, modtime) {
+		rangeReq = ""
+	}
+
 	return rangeReq, false
 }
 
+// matchesETag reports whether etag satisfies an If-None-Match header
+// value, which may be "*" or a comma-separated list of ETags, using weak
+// comparison (an ETag matches regardless of either side's W/ prefix).
+func matchesETag(etag, header string) bool {
+	return matchesETagList(etag, header, false)
+}
+
+// matchesETagStrong reports whether etag satisfies an If-Match header
+// value under RFC 7232 §3.1's strong comparison: a weak validator (either
+// etag itself, or an entry in header) never matches, even if the
+// underlying opaque tags are equal. A header of "*" matches any non-empty
+// etag, weak or strong.
+func matchesETagStrong(etag, header string) bool {
+	return matchesETagList(etag, header, true)
+}
+
+// matchesETagList implements the shared matching logic for If-Match and
+// If-None-Match: header may be "*" or a comma-separated list of ETags.
+func matchesETagList(etag, header string, strong bool) bool {
+	if etag == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+	if strong && strings.HasPrefix(etag, "W/") {
+		return false
+	}
+	want := etag
+	if !strong {
+		want = strings.TrimPrefix(etag, "W/")
+	}
+	for _, part := range strings.Split(header, ",") {
+		part = strings.TrimSpace(part)
+		if strong && strings.HasPrefix(part, "W/") {
+			continue
+		}
+		if !strong {
+			part = strings.TrimPrefix(part, "W/")
+		}
+		if part == want {
+			return true
+		}
+	}
+	return false
+}
+
+// rangeMatchesIfRange reports whether an If-Range header value matches the
+// current entity, by ETag if it looks like one, otherwise by comparing it
+// as a Last-Modified date.
+func rangeMatchesIfRange(ifRange, etag string, modtime time.Time) bool {
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		return ifRange == etag
+	}
+	if modtime.IsZero() {
+		return false
+	}
+	t, err := http.ParseTime(ifRange)
+	if err != nil {
+		return false
+	}
+	return modtime.Truncate(time.Second).Equal(t)
+}
+
 // httpRange specifies the byte range to be sent to the client.
 type httpRange struct {
 	start, length int64
@@ -399,6 +1019,11 @@ func (r httpRange) mimeHeader(contentType string, size int64) textproto.MIMEHead
 	}
 }
 
+// errNoOverlap is returned by parseRange when none of the ranges in the
+// request overlap the file, matching net/http's ServeContent semantics:
+// callers should respond 416 with a "Content-Range: bytes */<size>" header.
+var errNoOverlap = errors.New("invalid range: failed to overlap")
+
 // parseRange parses a Range header string as per RFC 2616.
 func parseRange(s string, size int64) ([]httpRange, error) {
 	if s == "" {
@@ -409,6 +1034,7 @@ func parseRange(s string, size int64) ([]httpRange, error) {
 		return nil, errors.New("invalid range")
 	}
 	var ranges []httpRange
+	noOverlap := false
 	for _, ra := range strings.Split(s[len(b):], ",") {
 		ra = strings.TrimSpace(ra)
 		if ra == "" {
@@ -434,9 +1060,15 @@ func parseRange(s string, size int64) ([]httpRange, error) {
 			r.length = size - r.start
 		} else {
 			i, err := strconv.ParseInt(start, 10, 64)
-			if err != nil || i > size || i < 0 {
+			if err != nil || i < 0 {
 				return nil, errors.New("invalid range")
 			}
+			if i >= size {
+				// If the range begins after the size of the content,
+				// then it does not overlap.
+				noOverlap = true
+				continue
+			}
 			r.start = i
 			if end == "" {
 				// If no end is specified, range extends to end of the file.
@@ -454,6 +1086,9 @@ func parseRange(s string, size int64) ([]httpRange, error) {
 		}
 		ranges = append(ranges, r)
 	}
+	if noOverlap && len(ranges) == 0 {
+		return nil, errNoOverlap
+	}
 	return ranges, nil
 }
 
@@ -1,11 +1,14 @@
 package zipassets
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 )
 
 func TestOpenZip(t *testing.T) {
-	za := &ZipAssets{"./testdata/assets.zip", make(map[string]*filecontent)}
+	za := &ZipAssets{path: "./testdata/assets.zip", files: make(map[string]*filecontent)}
 	err := openZip(za)
 	if err != nil {
 		t.Fatal(err)
@@ -14,4 +17,121 @@ func TestOpenZip(t *testing.T) {
 
 func TestNewZipAssets(t *testing.T) {
 	hdl, err := NewZipAssets("./testdata/assets.zip", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hdl == nil {
+		t.Fatal("expected a handler")
+	}
+}
+
+func newTestZipAssets() *ZipAssets {
+	return &ZipAssets{
+		path: "test.zip",
+		files: map[string]*filecontent{
+			"static/app.js": {name: "static/app.js", ranger: byteRanger("console.log(1);")},
+			"index.html":    {name: "index.html", ranger: byteRanger("<html>root</html>")},
+			"static/index.html": {
+				name:   "static/index.html",
+				ranger: byteRanger("<html>static</html>"),
+			},
+		},
+	}
+}
+
+func TestZipAssetsOpenFile(t *testing.T) {
+	za := newTestZipAssets()
+
+	f, err := za.Open("static/app.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.IsDir() {
+		t.Fatal("static/app.js should not be a directory")
+	}
+	if info.Size() != int64(len("console.log(1);")) {
+		t.Fatalf("unexpected size %d", info.Size())
+	}
+}
+
+func TestZipAssetsOpenImplicitDir(t *testing.T) {
+	za := newTestZipAssets()
+
+	f, err := za.Open("static")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.IsDir() {
+		t.Fatal("static should be treated as a directory")
+	}
+
+	children, err := f.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(children) != 2 {
+		t.Fatalf("expected 2 children, got %d", len(children))
+	}
+}
+
+func TestServeHTTPServesIndexHTML(t *testing.T) {
+	za := newTestZipAssets()
+
+	req := httptest.NewRequest(http.MethodGet, "/static/", nil)
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rw.Code)
+	}
+	if body := rw.Body.String(); body != "<html>static</html>" {
+		t.Fatalf("expected static/index.html contents, got %q", body)
+	}
+}
+
+func TestServeHTTPDirListing(t *testing.T) {
+	za := &ZipAssets{
+		path: "test.zip",
+		files: map[string]*filecontent{
+			"static/app.js": {name: "static/app.js", ranger: byteRanger("x")},
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/static/", nil)
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rw.Code)
+	}
+	if body := rw.Body.String(); !strings.Contains(body, "app.js") {
+		t.Fatalf("expected listing to contain app.js, got %q", body)
+	}
+}
+
+func TestServeHTTPDirRedirectsToTrailingSlash(t *testing.T) {
+	za := newTestZipAssets()
+
+	req := httptest.NewRequest(http.MethodGet, "/static", nil)
+	rw := httptest.NewRecorder()
+	za.ServeHTTP(rw, req)
+
+	if rw.Code != http.StatusMovedPermanently {
+		t.Fatalf("expected 301, got %d", rw.Code)
+	}
+	if loc := rw.Header().Get("Location"); loc != "static/" {
+		t.Fatalf("expected redirect to static/, got %q", loc)
+	}
 }